@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PVCAutoscalerBlockMetricQueryAnnotation configures a Block-mode PVC to
+// drive its expansion decision from an external metric query (e.g. a
+// Prometheus expression) resolved through metricsClient, since kubelet never
+// publishes VolumeUsedBytes for raw block devices.
+const PVCAutoscalerBlockMetricQueryAnnotation = "pvc.autoscaler.io/block-metric-query"
+
+// PVCAutoscalerBlockGrowEveryAnnotation is the fallback growth signal for
+// Block-mode PVCs: grow on a fixed timer instead of reacting to usage, e.g.
+// "24h".
+const PVCAutoscalerBlockGrowEveryAnnotation = "pvc.autoscaler.io/block-grow-every"
+
+// lastBlockGrow tracks, per PVC, the last time a timer-based Block growth
+// fired, so growEvery can be enforced across reconcile loops.
+var lastBlockGrow = struct {
+	sync.Mutex
+	m map[types.NamespacedName]time.Time
+}{m: make(map[types.NamespacedName]time.Time)}
+
+// shouldGrowBlockPVC decides whether a Block-mode PVC is due for expansion.
+// Block PVCs never get VolumeUsedBytes from kubelet (the container sees a
+// raw device, not a filesystem), so the usual usage-vs-threshold comparison
+// is meaningless here. Instead the PVC must opt into one of two alternative
+// signals: an external metric query resolved through metricsClient, or a
+// fixed-interval growth timer. A PVC with neither annotation is left alone.
+func (a *PVCAutoscaler) shouldGrowBlockPVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim, threshold int64) (bool, error) {
+	pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
+
+	if query, ok := pvc.Annotations[PVCAutoscalerBlockMetricQueryAnnotation]; ok && query != "" {
+		value, err := a.metricsClient.QueryScalar(ctx, query)
+		if err != nil {
+			return false, fmt.Errorf("failed to evaluate block-metric-query for PVC %s: %w", pvcId, err)
+		}
+		return value >= float64(threshold), nil
+	}
+
+	interval, ok := pvc.Annotations[PVCAutoscalerBlockGrowEveryAnnotation]
+	if !ok || interval == "" {
+		a.logger.WithField("id", pvcId).Debug("block pvc has neither a metric query nor a grow-every annotation, skipping")
+		return false, nil
+	}
+
+	growEvery, err := time.ParseDuration(interval)
+	if err != nil {
+		return false, fmt.Errorf("failed to parse %s annotation %q for PVC %s: %w", PVCAutoscalerBlockGrowEveryAnnotation, interval, pvcId, err)
+	}
+
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+	lastBlockGrow.Lock()
+	last, seen := lastBlockGrow.m[namespacedName]
+	lastBlockGrow.Unlock()
+
+	if seen && time.Since(last) < growEvery {
+		return false, nil
+	}
+
+	lastBlockGrow.Lock()
+	lastBlockGrow.m[namespacedName] = time.Now()
+	lastBlockGrow.Unlock()
+
+	return true, nil
+}
+
+// noteBlockVolumeExpanded records that a Block-mode PVC was resized, and
+// flags whether the CSI driver reported that node-side expansion is
+// required. Kubernetes surfaces that signal the same way for every volume
+// mode: the external-resizer sets the PVC's FileSystemResizePending
+// condition whenever ControllerExpandVolume's response had
+// NodeExpansionRequired set, regardless of whether there's actually a
+// filesystem to resize. On a Block PVC kubelet never acts on that condition
+// (there's no filesystem to grow), so if it's set the in-guest consumer
+// (e.g. the database running in the pod) is the one responsible for
+// detecting and using the new device size.
+//
+// The condition is set asynchronously by the CSI driver some time after the
+// spec update lands, so pvc (the copy the caller just resized) is re-fetched
+// here rather than trusting its in-memory conditions, which still reflect
+// the PVC's state from before this resize.
+func (a *PVCAutoscaler) noteBlockVolumeExpanded(ctx context.Context, pvc *corev1.PersistentVolumeClaim) {
+	pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
+
+	fresh, err := a.kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Get(ctx, pvc.Name, metav1.GetOptions{})
+	if err != nil {
+		a.logger.WithFields(log.Fields{"pvcId": pvcId, "error": err}).Warn("failed to re-fetch block pvc to check for node expansion, deferring to the next reconcile pass")
+		return
+	}
+
+	_, nodeExpansionRequired := pvcResizeConditions(fresh)
+
+	var message string
+	if nodeExpansionRequired {
+		message = "volume resized in Block mode; the CSI driver reports NodeExpansionRequired (FileSystemResizePending), but kubelet does not resize a filesystem on a raw block device, so the workload must detect and use the new device size itself"
+		a.logger.WithField("id", pvcId).Warn("block pvc resized: CSI driver reports node expansion is required, no filesystem resize will happen on the node")
+	} else {
+		message = "volume resized in Block mode; the CSI driver does not report a pending node expansion, the block device should already reflect the new size"
+		a.logger.WithField("id", pvcId).Info("block pvc resized: CSI driver does not report node expansion as required")
+	}
+
+	if err := a.emitEvent(ctx, fresh, "PersistentVolumeClaim", corev1.EventTypeNormal, "BlockVolumeExpanded", message); err != nil {
+		a.logger.WithFields(log.Fields{"pvcId": pvcId, "error": err}).Warn("failed to emit BlockVolumeExpanded event")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// eventSourceComponent identifies the autoscaler as the source of any Event
+// it records.
+const eventSourceComponent = "pvc-autoscaler"
+
+// emitEvent records a Kubernetes Event against obj. It is a thin wrapper
+// around the Events API rather than a full record.EventRecorder, since the
+// autoscaler has no informer cache to back one.
+func (a *PVCAutoscaler) emitEvent(ctx context.Context, obj metav1.Object, kind, eventType, reason, message string) error {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-", obj.GetName()),
+			Namespace:    obj.GetNamespace(),
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:      kind,
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			UID:       obj.GetUID(),
+		},
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Source: corev1.EventSource{
+			Component: eventSourceComponent,
+		},
+	}
+
+	_, err := a.kubeClient.CoreV1().Events(obj.GetNamespace()).Create(ctx, event, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create event %q on %s %s/%s: %w", reason, kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	return nil
+}
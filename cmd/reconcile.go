@@ -4,7 +4,6 @@ import (
 	"context"
 	"fmt"
 	log "github.com/sirupsen/logrus"
-	"math"
 	"strconv"
 	"time"
 
@@ -32,6 +31,15 @@ func (a *PVCAutoscaler) reconcile(ctx context.Context) error {
 		pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
 		a.logger.WithField("id", pvcId).Debug("processing pvc")
 
+		if state := loadResizeState(&pvc); !state.isRetryDue() {
+			a.logger.WithFields(log.Fields{
+				"id":        pvcId,
+				"phase":     state.phase,
+				"nextRetry": state.nextRetry,
+			}).Debug("pvc is backing off from a previous failed resize, skipping")
+			continue
+		}
+
 		// Determine if the StorageClass allows volume expansion
 		storageClassName := *pvc.Spec.StorageClassName
 		isExpandable, err := isStorageClassExpandable(ctx, a.kubeClient, storageClassName)
@@ -94,13 +102,21 @@ func (a *PVCAutoscaler) reconcile(ctx context.Context) error {
 			continue
 		}
 
-		increase, err := convertPercentageToBytes(pvc.Annotations[PVCAutoscalerIncreaseAnnotation], capacity.Value(), DefaultIncrease)
-		if err != nil {
-			a.logger.WithFields(log.Fields{
-				"pvcId": pvcId,
-				"error": err,
-			}).Error("failed to convert increase annotation")
-			continue
+		previousUsageHistory := loadUsageHistory(&pvc)
+		usageSample := UsageSample{
+			Timestamp: time.Now(),
+			UsedBytes: pvcsMetrics[namespacedName].VolumeUsedBytes,
+		}
+		usageHistory := appendUsageSample(previousUsageHistory, usageSample)
+		if shouldPersistUsageSample(previousUsageHistory, usageSample, capacity.Value()) {
+			if updated, err := a.persistUsageHistory(ctx, &pvc, usageHistory); err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Warn("failed to persist usage history")
+			} else {
+				pvc = *updated
+			}
 		}
 
 		previousCapacity, exist := pvc.Annotations[PVCAutoscalerPreviousCapacityAnnotation]
@@ -112,8 +128,33 @@ func (a *PVCAutoscaler) reconcile(ctx context.Context) error {
 			}
 			if parsedPreviousCapacity == pvcCurrentCapacityBytes {
 				a.logger.WithField("id", pvcId).Info("pvc is still waiting to accept the resize")
+				if err := a.checkStuckFilesystemResize(ctx, &pvc); err != nil {
+					a.logger.WithFields(log.Fields{
+						"pvcId": pvcId,
+						"error": err,
+					}).Error("failed to check for a stuck filesystem resize")
+				}
+				if loadResizeState(&pvc).deadlineExceeded(StuckResizeThreshold) {
+					a.logger.WithField("id", pvcId).Warn("pvc has not completed its resize within the stuck-resize threshold, recording a failure")
+					if _, err := a.recordResizeFailure(ctx, &pvc); err != nil {
+						a.logger.WithFields(log.Fields{
+							"pvcId": pvcId,
+							"error": err,
+						}).Error("failed to record resize failure")
+					}
+				}
 				continue
 			}
+
+			a.logger.WithField("id", pvcId).Debug("pvc capacity advanced since last resize, clearing backoff state")
+			if updated, err := a.clearResizeState(ctx, &pvc); err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to clear resize backoff state")
+			} else {
+				pvc = *updated
+			}
 		}
 
 		ceiling, err := getPVCStorageCeiling(&pvc)
@@ -132,23 +173,108 @@ func (a *PVCAutoscaler) reconcile(ctx context.Context) error {
 			continue
 		}
 
-		currentUsedBytes := pvcsMetrics[namespacedName].VolumeUsedBytes
-		if currentUsedBytes >= threshold {
+		isBlockVolume := pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == corev1.PersistentVolumeBlock
+
+		var needsResize bool
+		if isBlockVolume {
+			needsResize, err = a.shouldGrowBlockPVC(ctx, &pvc, threshold)
+			if err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to evaluate growth signal for block pvc")
+				continue
+			}
+		} else {
+			currentUsedBytes := pvcsMetrics[namespacedName].VolumeUsedBytes
+			needsResize = currentUsedBytes >= threshold
+		}
+
+		if needsResize {
 			a.logger.WithField("id", pvcId).Info("pvc usage bigger than threshold")
 
-			// 1<<30 is a bit shift operation that represents 2^30, i.e. 1Gi
-			newStorageBytes := int64(math.Ceil(float64(capacity.Value()+increase)/(1<<30))) << 30
-			newStorage := resource.NewQuantity(newStorageBytes, resource.BinarySI)
+			if updated, err := a.setResizePhase(ctx, &pvc, VolumePhasePending); err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to record pending phase")
+			} else {
+				pvc = *updated
+			}
+
+			policy := scalingPolicyFor(&pvc)
+			newStorage, err := policy.NextSize(ctx, &pvc, ScalingMetrics{
+				CapacityBytes: capacity.Value(),
+				UsedBytes:     pvcsMetrics[namespacedName].VolumeUsedBytes,
+			}, usageHistory, ceiling)
+			if err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to compute next size from scaling policy")
+				continue
+			}
+
+			unitBytes := storageClassAllocationUnitBytes(ctx, a, storageClassName)
+			newStorage = resource.NewQuantity(snapUpToAllocationUnit(newStorage.Value(), unitBytes), resource.BinarySI)
 			if newStorage.Cmp(ceiling) > 0 {
 				newStorage = &ceiling
 			}
 
-			err := a.updatePVCWithNewStorageSize(ctx, &pvc, pvcCurrentCapacityBytes, newStorage)
+			if pvc.Annotations[PVCAutoscalerOwnerSyncAnnotation] == ownerSyncStatefulSet {
+				if stsName, ok := ownerStatefulSetName(&pvc); ok {
+					if templateName, ok := templateNameForPVC(&pvc, stsName); ok {
+						if err := a.syncStatefulSetVolumeClaimTemplate(ctx, pvc.Namespace, stsName, templateName, pvc.Name, newStorage); err != nil {
+							a.logger.WithFields(log.Fields{
+								"pvcId": pvcId,
+								"error": err,
+							}).Error("failed to sync owning StatefulSet's volumeClaimTemplate")
+						}
+					} else {
+						a.logger.WithField("id", pvcId).Warn("owner-sync annotation set but could not determine volumeClaimTemplate name")
+					}
+				} else {
+					a.logger.WithField("id", pvcId).Warn("owner-sync annotation set but pvc has no owning StatefulSet")
+				}
+			}
+
+			if updated, err := a.setResizePhase(ctx, &pvc, VolumePhasePreparing); err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to record preparing phase")
+			} else {
+				pvc = *updated
+			}
+
+			updated, err := a.updatePVCWithNewStorageSize(ctx, &pvc, pvcCurrentCapacityBytes, newStorage)
 			if err != nil {
 				a.logger.WithFields(log.Fields{
 					"pvcId": pvcId,
 					"error": err,
 				}).Error("failed to resize pvc")
+
+				if _, failErr := a.recordResizeFailure(ctx, &pvc); failErr != nil {
+					a.logger.WithFields(log.Fields{
+						"pvcId": pvcId,
+						"error": failErr,
+					}).Error("failed to record resize failure")
+				}
+				continue
+			}
+			pvc = *updated
+
+			if updated, err := a.setResizePhase(ctx, &pvc, VolumePhaseModified); err != nil {
+				a.logger.WithFields(log.Fields{
+					"pvcId": pvcId,
+					"error": err,
+				}).Error("failed to record modified phase")
+			} else {
+				pvc = *updated
+			}
+
+			if isBlockVolume {
+				a.noteBlockVolumeExpanded(ctx, &pvc)
 			}
 
 			a.logger.WithFields(log.Fields{
@@ -162,7 +288,9 @@ func (a *PVCAutoscaler) reconcile(ctx context.Context) error {
 	return nil
 }
 
-func (a *PVCAutoscaler) updatePVCWithNewStorageSize(ctx context.Context, pvcToResize *corev1.PersistentVolumeClaim, capacityBytes int64, newStorageBytes *resource.Quantity) error {
+// updatePVCWithNewStorageSize returns the object the API server stored (with
+// its bumped ResourceVersion) so callers can chain further writes onto it.
+func (a *PVCAutoscaler) updatePVCWithNewStorageSize(ctx context.Context, pvcToResize *corev1.PersistentVolumeClaim, capacityBytes int64, newStorageBytes *resource.Quantity) (*corev1.PersistentVolumeClaim, error) {
 	pvcId := fmt.Sprintf("%s/%s", pvcToResize.Namespace, pvcToResize.Name)
 
 	pvcToResize.Spec.Resources.Requests[corev1.ResourceStorage] = *newStorageBytes
@@ -170,11 +298,11 @@ func (a *PVCAutoscaler) updatePVCWithNewStorageSize(ctx context.Context, pvcToRe
 	pvcToResize.Annotations[PVCAutoscalerPreviousCapacityAnnotation] = strconv.FormatInt(capacityBytes, 10)
 	a.logger.WithField("id", pvcId).Debug("PVCAutoscalerPreviousCapacityAnnotation annotation written")
 
-	_, err := a.kubeClient.CoreV1().PersistentVolumeClaims(pvcToResize.Namespace).Update(ctx, pvcToResize, metav1.UpdateOptions{})
+	updated, err := a.kubeClient.CoreV1().PersistentVolumeClaims(pvcToResize.Namespace).Update(ctx, pvcToResize, metav1.UpdateOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to update PVC %s: %w", pvcId, err)
+		return nil, fmt.Errorf("failed to update PVC %s: %w", pvcId, err)
 	}
 	a.logger.WithField("id", pvcId).Debug("update function called and returned no error")
 
-	return nil
+	return updated, nil
 }
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// VolumePhase models where a PVC sits in the resize lifecycle, modeled on
+// tidb-operator's getVolumePhase.
+type VolumePhase string
+
+const (
+	VolumePhaseIdle      VolumePhase = "Idle"
+	VolumePhasePending   VolumePhase = "Pending"
+	VolumePhasePreparing VolumePhase = "Preparing"
+	VolumePhaseModified  VolumePhase = "Modified"
+	VolumePhaseFailed    VolumePhase = "Failed"
+)
+
+// Annotations used to persist resize state across autoscaler restarts.
+const (
+	PVCAutoscalerPhaseAnnotation        = "pvc.autoscaler.io/phase"
+	PVCAutoscalerFailureCountAnnotation = "pvc.autoscaler.io/failure-count"
+	PVCAutoscalerNextRetryAnnotation    = "pvc.autoscaler.io/next-retry"
+	PVCAutoscalerPhaseSinceAnnotation   = "pvc.autoscaler.io/phase-since"
+)
+
+// Defaults for the --retry-interval-start and --retry-interval-max flags,
+// following the CSI external-resizer convention.
+const (
+	DefaultRetryIntervalStart = 1 * time.Second
+	DefaultRetryIntervalMax   = 5 * time.Minute
+)
+
+// RetryIntervalStart and RetryIntervalMax bound the exponential backoff
+// applied between resize attempts on a failing PVC. They are set from the
+// --retry-interval-start and --retry-interval-max flags at startup.
+var (
+	RetryIntervalStart = DefaultRetryIntervalStart
+	RetryIntervalMax   = DefaultRetryIntervalMax
+)
+
+// resizeState tracks the backoff state for a single PVC.
+type resizeState struct {
+	phase        VolumePhase
+	failureCount int
+	nextRetry    time.Time
+	phaseSince   time.Time
+}
+
+// resizeStates is the in-memory mirror of the per-PVC state persisted via
+// annotations. Annotations survive autoscaler restarts; the map avoids a Get
+// round-trip on every reconcile loop for PVCs that aren't in backoff.
+var resizeStates = struct {
+	sync.Mutex
+	m map[types.NamespacedName]*resizeState
+}{m: make(map[types.NamespacedName]*resizeState)}
+
+// loadResizeState returns the current resize state for pvc, preferring the
+// in-memory copy and falling back to annotations (e.g. after a restart).
+func loadResizeState(pvc *corev1.PersistentVolumeClaim) *resizeState {
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+
+	resizeStates.Lock()
+	if state, ok := resizeStates.m[namespacedName]; ok {
+		resizeStates.Unlock()
+		return state
+	}
+	resizeStates.Unlock()
+
+	state := &resizeState{phase: VolumePhaseIdle}
+
+	if phase, ok := pvc.Annotations[PVCAutoscalerPhaseAnnotation]; ok {
+		state.phase = VolumePhase(phase)
+	}
+	if raw, ok := pvc.Annotations[PVCAutoscalerFailureCountAnnotation]; ok {
+		if count, err := strconv.Atoi(raw); err == nil {
+			state.failureCount = count
+		}
+	}
+	if raw, ok := pvc.Annotations[PVCAutoscalerNextRetryAnnotation]; ok {
+		if nextRetry, err := time.Parse(time.RFC3339, raw); err == nil {
+			state.nextRetry = nextRetry
+		}
+	}
+	if raw, ok := pvc.Annotations[PVCAutoscalerPhaseSinceAnnotation]; ok {
+		if phaseSince, err := time.Parse(time.RFC3339, raw); err == nil {
+			state.phaseSince = phaseSince
+		}
+	}
+
+	resizeStates.Lock()
+	resizeStates.m[namespacedName] = state
+	resizeStates.Unlock()
+
+	return state
+}
+
+// isRetryDue reports whether state allows another resize attempt now.
+func (s *resizeState) isRetryDue() bool {
+	return s.phase != VolumePhaseFailed || !time.Now().Before(s.nextRetry)
+}
+
+// deadlineExceeded reports whether pvc has been stuck in Preparing or
+// Modified (spec updated but the resize never finished landing) for longer
+// than deadline, per the request's "resize does not complete within a
+// configurable deadline" condition.
+func (s *resizeState) deadlineExceeded(deadline time.Duration) bool {
+	if s.phase != VolumePhasePreparing && s.phase != VolumePhaseModified {
+		return false
+	}
+	if s.phaseSince.IsZero() {
+		return false
+	}
+	return time.Since(s.phaseSince) > deadline
+}
+
+// nextBackoff computes the retry delay to use after failureCount failures:
+// start itself for the first retry, doubling each failure after that and
+// capping at max.
+func nextBackoff(failureCount int, start, max time.Duration) time.Duration {
+	if failureCount <= 1 {
+		return start
+	}
+	backoff := start
+	for i := 1; i < failureCount; i++ {
+		backoff *= 2
+		if backoff >= max {
+			return max
+		}
+	}
+	return backoff
+}
+
+// recordResizeFailure advances pvc into the Failed phase, bumps its failure
+// count, and schedules the next retry using exponential backoff. The new
+// state is persisted both in memory and as annotations on pvc; the returned
+// PVC carries the bumped ResourceVersion from that Update call and must be
+// used for any further writes in the same reconcile pass.
+func (a *PVCAutoscaler) recordResizeFailure(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+	state := loadResizeState(pvc)
+
+	state.phase = VolumePhaseFailed
+	state.failureCount++
+	state.nextRetry = time.Now().Add(nextBackoff(state.failureCount, RetryIntervalStart, RetryIntervalMax))
+	state.phaseSince = time.Now()
+
+	resizeStates.Lock()
+	resizeStates.m[namespacedName] = state
+	resizeStates.Unlock()
+
+	return a.persistResizeState(ctx, pvc, state)
+}
+
+// setResizePhase moves pvc to phase without affecting its failure count or
+// backoff deadline, and persists the change. The returned PVC carries the
+// bumped ResourceVersion from that Update call and must be used for any
+// further writes in the same reconcile pass.
+func (a *PVCAutoscaler) setResizePhase(ctx context.Context, pvc *corev1.PersistentVolumeClaim, phase VolumePhase) (*corev1.PersistentVolumeClaim, error) {
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+	state := loadResizeState(pvc)
+	if state.phase != phase {
+		state.phaseSince = time.Now()
+	}
+	state.phase = phase
+
+	resizeStates.Lock()
+	resizeStates.m[namespacedName] = state
+	resizeStates.Unlock()
+
+	return a.persistResizeState(ctx, pvc, state)
+}
+
+// clearResizeState resets pvc to Idle and drops its failure count and
+// backoff deadline, in memory and via annotations. It's called once a resize
+// is observed to have completed (Bound with the new capacity). The returned
+// PVC carries the bumped ResourceVersion from that Update call and must be
+// used for any further writes in the same reconcile pass.
+func (a *PVCAutoscaler) clearResizeState(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*corev1.PersistentVolumeClaim, error) {
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+
+	resizeStates.Lock()
+	delete(resizeStates.m, namespacedName)
+	resizeStates.Unlock()
+
+	return a.persistResizeState(ctx, pvc, &resizeState{phase: VolumePhaseIdle})
+}
+
+// persistResizeState writes state onto pvc's annotations and updates it in
+// the API server, returning the object the API server stored (with its
+// bumped ResourceVersion) so callers can chain further writes onto it.
+func (a *PVCAutoscaler) persistResizeState(ctx context.Context, pvc *corev1.PersistentVolumeClaim, state *resizeState) (*corev1.PersistentVolumeClaim, error) {
+	pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
+
+	if state.phase == VolumePhaseIdle {
+		delete(pvc.Annotations, PVCAutoscalerPhaseAnnotation)
+		delete(pvc.Annotations, PVCAutoscalerFailureCountAnnotation)
+		delete(pvc.Annotations, PVCAutoscalerNextRetryAnnotation)
+		delete(pvc.Annotations, PVCAutoscalerPhaseSinceAnnotation)
+	} else {
+		pvc.Annotations[PVCAutoscalerPhaseAnnotation] = string(state.phase)
+		pvc.Annotations[PVCAutoscalerFailureCountAnnotation] = strconv.Itoa(state.failureCount)
+		if !state.nextRetry.IsZero() {
+			pvc.Annotations[PVCAutoscalerNextRetryAnnotation] = state.nextRetry.Format(time.RFC3339)
+		}
+		if !state.phaseSince.IsZero() {
+			pvc.Annotations[PVCAutoscalerPhaseSinceAnnotation] = state.phaseSince.Format(time.RFC3339)
+		}
+	}
+
+	updated, err := a.kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist resize state for PVC %s: %w", pvcId, err)
+	}
+	a.logger.WithFields(log.Fields{
+		"id":    pvcId,
+		"phase": state.phase,
+	}).Debug("persisted resize state")
+	return updated, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextBackoff(t *testing.T) {
+	start := 1 * time.Second
+	max := 5 * time.Minute
+
+	tests := []struct {
+		name         string
+		failureCount int
+		want         time.Duration
+	}{
+		{"first failure waits start", 1, start},
+		{"second failure doubles", 2, 2 * time.Second},
+		{"third failure doubles again", 3, 4 * time.Second},
+		{"zero failures still waits start", 0, start},
+		{"caps at max", 20, max},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := nextBackoff(tt.failureCount, start, max)
+			if got != tt.want {
+				t.Errorf("nextBackoff(%d, %s, %s) = %s, want %s", tt.failureCount, start, max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizeStateDeadlineExceeded(t *testing.T) {
+	tests := []struct {
+		name     string
+		state    resizeState
+		deadline time.Duration
+		want     bool
+	}{
+		{
+			name:     "idle phase never exceeds deadline",
+			state:    resizeState{phase: VolumePhaseIdle, phaseSince: time.Now().Add(-time.Hour)},
+			deadline: time.Minute,
+			want:     false,
+		},
+		{
+			name:     "preparing phase within deadline",
+			state:    resizeState{phase: VolumePhasePreparing, phaseSince: time.Now()},
+			deadline: time.Minute,
+			want:     false,
+		},
+		{
+			name:     "preparing phase past deadline",
+			state:    resizeState{phase: VolumePhasePreparing, phaseSince: time.Now().Add(-time.Hour)},
+			deadline: time.Minute,
+			want:     true,
+		},
+		{
+			name:     "modified phase past deadline",
+			state:    resizeState{phase: VolumePhaseModified, phaseSince: time.Now().Add(-time.Hour)},
+			deadline: time.Minute,
+			want:     true,
+		},
+		{
+			name:     "zero phaseSince never exceeds deadline",
+			state:    resizeState{phase: VolumePhasePreparing},
+			deadline: time.Minute,
+			want:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.deadlineExceeded(tt.deadline); got != tt.want {
+				t.Errorf("deadlineExceeded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PVCAutoscalerPolicyAnnotation selects which ScalingPolicy computes a PVC's
+// next size. Unset or unrecognized values fall back to fixed-percent, the
+// autoscaler's original behavior.
+const PVCAutoscalerPolicyAnnotation = "pvc.autoscaler.io/policy"
+
+// PVCAutoscalerMinIncreaseAnnotation floors, in bytes, the increase any
+// policy computes, so a resize is never too small to be worth the CSI
+// round-trip.
+const PVCAutoscalerMinIncreaseAnnotation = "pvc.autoscaler.io/min-increase-bytes"
+
+// PVCAutoscalerTargetRatioAnnotation configures the target-headroom policy's
+// desired VolumeUsedBytes/newSize ratio, e.g. "0.7" for 70%.
+const PVCAutoscalerTargetRatioAnnotation = "pvc.autoscaler.io/target-ratio"
+
+// PVCAutoscalerTrendHorizonAnnotation configures the trend policy's desired
+// projected-fill horizon, e.g. "24h".
+const PVCAutoscalerTrendHorizonAnnotation = "pvc.autoscaler.io/trend-horizon"
+
+// PVCAutoscalerHistoryAnnotation stores a gzip+base64 encoded ring of recent
+// usage samples, so the trend policy survives autoscaler restarts.
+const PVCAutoscalerHistoryAnnotation = "pvc.autoscaler.io/usage-history"
+
+// PVCAutoscalerAllocationUnitAnnotation, set on a StorageClass, overrides the
+// byte boundary a ScalingPolicy's result is snapped up to. Kubernetes has no
+// native notion of this, so it's opt-in. Defaults to 1Gi.
+const PVCAutoscalerAllocationUnitAnnotation = "pvc.autoscaler.io/allocation-unit-bytes"
+
+const (
+	defaultAllocationUnitBytes = 1 << 30 // 1Gi, matching reconcile's historical rounding
+	defaultTargetRatio         = 0.7
+	defaultTrendHorizon        = 24 * time.Hour
+	maxHistorySamples          = 30
+
+	// minHistorySampleDeltaRatio bounds how often a usage sample is written
+	// back to the PVC: only once VolumeUsedBytes has moved by at least this
+	// fraction of capacity since the last persisted sample. Without this, an
+	// Update() would fire for every annotated PVC on every reconcile tick
+	// just to record a sample, regardless of whether a resize is happening.
+	minHistorySampleDeltaRatio = 0.01
+)
+
+// Values accepted by PVCAutoscalerPolicyAnnotation.
+const (
+	ScalingPolicyFixedPercent   = "fixed-percent"
+	ScalingPolicyTargetHeadroom = "target-headroom"
+	ScalingPolicyTrend          = "trend"
+)
+
+// ScalingMetrics is the subset of a PVC's latest metrics sample a
+// ScalingPolicy needs to compute its next size.
+type ScalingMetrics struct {
+	CapacityBytes int64
+	UsedBytes     int64
+}
+
+// UsageSample is one point in a PVC's usage history, used by policies (like
+// trend) that need more than the latest sample.
+type UsageSample struct {
+	Timestamp time.Time `json:"t"`
+	UsedBytes int64     `json:"u"`
+}
+
+// ScalingPolicy computes the next size a PVC should be grown to. A policy
+// must enforce PVCAutoscalerMinIncreaseAnnotation itself and never return a
+// size above ceiling; reconcile is responsible for snapping the result to
+// the StorageClass's allocation unit afterwards.
+type ScalingPolicy interface {
+	NextSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, metrics ScalingMetrics, history []UsageSample, ceiling resource.Quantity) (*resource.Quantity, error)
+}
+
+// scalingPolicyFor resolves the ScalingPolicy named by
+// PVCAutoscalerPolicyAnnotation, defaulting to fixed-percent.
+func scalingPolicyFor(pvc *corev1.PersistentVolumeClaim) ScalingPolicy {
+	switch pvc.Annotations[PVCAutoscalerPolicyAnnotation] {
+	case ScalingPolicyTargetHeadroom:
+		return targetHeadroomPolicy{}
+	case ScalingPolicyTrend:
+		return trendPolicy{}
+	default:
+		return fixedPercentPolicy{}
+	}
+}
+
+// fixedPercentPolicy is the autoscaler's original behavior: grow capacity by
+// a fixed percentage, set via PVCAutoscalerIncreaseAnnotation.
+type fixedPercentPolicy struct{}
+
+func (fixedPercentPolicy) NextSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, metrics ScalingMetrics, history []UsageSample, ceiling resource.Quantity) (*resource.Quantity, error) {
+	increase, err := convertPercentageToBytes(pvc.Annotations[PVCAutoscalerIncreaseAnnotation], metrics.CapacityBytes, DefaultIncrease)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert increase annotation: %w", err)
+	}
+	increase = applyMinIncrease(pvc, increase)
+
+	size := resource.NewQuantity(metrics.CapacityBytes+increase, resource.BinarySI)
+	return clampToCeiling(size, ceiling), nil
+}
+
+// targetHeadroomPolicy solves for the smallest size that brings
+// VolumeUsedBytes/newSize down to PVCAutoscalerTargetRatioAnnotation (default
+// 70%), so a nearly-full disk jumps straight to the right size instead of
+// needing several reconcile passes.
+type targetHeadroomPolicy struct{}
+
+func (targetHeadroomPolicy) NextSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, metrics ScalingMetrics, history []UsageSample, ceiling resource.Quantity) (*resource.Quantity, error) {
+	targetRatio := defaultTargetRatio
+	if raw, ok := pvc.Annotations[PVCAutoscalerTargetRatioAnnotation]; ok {
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s annotation %q: %w", PVCAutoscalerTargetRatioAnnotation, raw, err)
+		}
+		targetRatio = parsed
+	}
+	if targetRatio <= 0 || targetRatio >= 1 {
+		return nil, fmt.Errorf("%s must be between 0 and 1, got %v", PVCAutoscalerTargetRatioAnnotation, targetRatio)
+	}
+
+	wanted := int64(math.Ceil(float64(metrics.UsedBytes) / targetRatio))
+	increase := applyMinIncrease(pvc, maxInt64(wanted-metrics.CapacityBytes, 0))
+
+	size := resource.NewQuantity(metrics.CapacityBytes+increase, resource.BinarySI)
+	return clampToCeiling(size, ceiling), nil
+}
+
+// trendPolicy fits a linear trend through the PVC's recent usage history and
+// sizes the volume so the projected time to fill stays above
+// PVCAutoscalerTrendHorizonAnnotation (default 24h).
+type trendPolicy struct{}
+
+func (trendPolicy) NextSize(ctx context.Context, pvc *corev1.PersistentVolumeClaim, metrics ScalingMetrics, history []UsageSample, ceiling resource.Quantity) (*resource.Quantity, error) {
+	horizon := defaultTrendHorizon
+	if raw, ok := pvc.Annotations[PVCAutoscalerTrendHorizonAnnotation]; ok {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s annotation %q: %w", PVCAutoscalerTrendHorizonAnnotation, raw, err)
+		}
+		horizon = parsed
+	}
+
+	growthPerSecond, ok := fitLinearGrowthRate(history)
+	if !ok || growthPerSecond <= 0 {
+		// Not enough history, or a flat/shrinking trend: fall back to
+		// fixed-percent rather than refusing to grow at all.
+		return fixedPercentPolicy{}.NextSize(ctx, pvc, metrics, history, ceiling)
+	}
+
+	projectedGrowth := int64(growthPerSecond * horizon.Seconds())
+	wanted := metrics.UsedBytes + projectedGrowth
+	increase := applyMinIncrease(pvc, maxInt64(wanted-metrics.CapacityBytes, 0))
+
+	size := resource.NewQuantity(metrics.CapacityBytes+increase, resource.BinarySI)
+	return clampToCeiling(size, ceiling), nil
+}
+
+// fitLinearGrowthRate fits a least-squares line through history and returns
+// its slope in bytes/second. ok is false when there isn't enough history to
+// fit a meaningful trend.
+func fitLinearGrowthRate(history []UsageSample) (bytesPerSecond float64, ok bool) {
+	if len(history) < 2 {
+		return 0, false
+	}
+
+	t0 := history[0].Timestamp
+	var n, sumX, sumY, sumXY, sumXX float64
+	for _, sample := range history {
+		x := sample.Timestamp.Sub(t0).Seconds()
+		y := float64(sample.UsedBytes)
+		n++
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+
+	return (n*sumXY - sumX*sumY) / denominator, true
+}
+
+func applyMinIncrease(pvc *corev1.PersistentVolumeClaim, increase int64) int64 {
+	raw, ok := pvc.Annotations[PVCAutoscalerMinIncreaseAnnotation]
+	if !ok {
+		return increase
+	}
+	min, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || increase >= min {
+		return increase
+	}
+	return min
+}
+
+func clampToCeiling(size *resource.Quantity, ceiling resource.Quantity) *resource.Quantity {
+	if size.Cmp(ceiling) > 0 {
+		return &ceiling
+	}
+	return size
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// snapUpToAllocationUnit rounds valueBytes up to the next multiple of
+// unitBytes.
+func snapUpToAllocationUnit(valueBytes, unitBytes int64) int64 {
+	if unitBytes <= 0 {
+		unitBytes = defaultAllocationUnitBytes
+	}
+	return int64(math.Ceil(float64(valueBytes)/float64(unitBytes))) * unitBytes
+}
+
+// storageClassAllocationUnitBytes reads the allocation unit a StorageClass
+// wants its resizes snapped to, from PVCAutoscalerAllocationUnitAnnotation.
+// Falls back to 1Gi if the StorageClass can't be fetched or doesn't set it.
+func storageClassAllocationUnitBytes(ctx context.Context, a *PVCAutoscaler, storageClassName string) int64 {
+	sc, err := a.kubeClient.StorageV1().StorageClasses().Get(ctx, storageClassName, metav1.GetOptions{})
+	if err != nil {
+		return defaultAllocationUnitBytes
+	}
+
+	raw, ok := sc.Annotations[PVCAutoscalerAllocationUnitAnnotation]
+	if !ok {
+		return defaultAllocationUnitBytes
+	}
+
+	unit, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || unit <= 0 {
+		return defaultAllocationUnitBytes
+	}
+	return unit
+}
+
+// loadUsageHistory decodes the usage history ring stored on pvc's
+// PVCAutoscalerHistoryAnnotation. A missing or malformed annotation yields
+// an empty history rather than an error, since losing history should never
+// block a resize.
+func loadUsageHistory(pvc *corev1.PersistentVolumeClaim) []UsageSample {
+	raw, ok := pvc.Annotations[PVCAutoscalerHistoryAnnotation]
+	if !ok {
+		return nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(raw)
+	if err != nil {
+		return nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(reader)
+	if err != nil {
+		return nil
+	}
+
+	var history []UsageSample
+	if err := json.Unmarshal(decoded, &history); err != nil {
+		return nil
+	}
+	return history
+}
+
+// appendUsageSample appends sample to history, bounding it to
+// maxHistorySamples entries.
+func appendUsageSample(history []UsageSample, sample UsageSample) []UsageSample {
+	history = append(history, sample)
+	if len(history) > maxHistorySamples {
+		history = history[len(history)-maxHistorySamples:]
+	}
+	return history
+}
+
+// shouldPersistUsageSample reports whether sample is worth writing back to
+// the PVC, versus just keeping it in memory for this reconcile pass. history
+// is the ring as it was before sample was appended.
+func shouldPersistUsageSample(history []UsageSample, sample UsageSample, capacityBytes int64) bool {
+	if len(history) == 0 {
+		return true
+	}
+
+	delta := sample.UsedBytes - history[len(history)-1].UsedBytes
+	if delta < 0 {
+		delta = -delta
+	}
+
+	minDelta := int64(float64(capacityBytes) * minHistorySampleDeltaRatio)
+	return delta >= minDelta
+}
+
+// persistUsageHistory gzip+base64 encodes history onto pvc's
+// PVCAutoscalerHistoryAnnotation and updates it in the API server, returning
+// the object the API server stored (with its bumped ResourceVersion) so
+// callers can chain further writes onto it.
+func (a *PVCAutoscaler) persistUsageHistory(ctx context.Context, pvc *corev1.PersistentVolumeClaim, history []UsageSample) (*corev1.PersistentVolumeClaim, error) {
+	pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
+
+	encoded, err := json.Marshal(history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal usage history for PVC %s: %w", pvcId, err)
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(encoded); err != nil {
+		return nil, fmt.Errorf("failed to compress usage history for PVC %s: %w", pvcId, err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress usage history for PVC %s: %w", pvcId, err)
+	}
+
+	pvc.Annotations[PVCAutoscalerHistoryAnnotation] = base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	updated, err := a.kubeClient.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(ctx, pvc, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to persist usage history for PVC %s: %w", pvcId, err)
+	}
+	return updated, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFitLinearGrowthRate(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		history []UsageSample
+		wantOk  bool
+		want    float64
+	}{
+		{
+			name:    "too few samples",
+			history: []UsageSample{{Timestamp: base, UsedBytes: 100}},
+			wantOk:  false,
+		},
+		{
+			name: "steady growth of 1 byte per second",
+			history: []UsageSample{
+				{Timestamp: base, UsedBytes: 0},
+				{Timestamp: base.Add(1 * time.Second), UsedBytes: 1},
+				{Timestamp: base.Add(2 * time.Second), UsedBytes: 2},
+				{Timestamp: base.Add(3 * time.Second), UsedBytes: 3},
+			},
+			wantOk: true,
+			want:   1,
+		},
+		{
+			name: "flat usage yields zero slope",
+			history: []UsageSample{
+				{Timestamp: base, UsedBytes: 500},
+				{Timestamp: base.Add(time.Minute), UsedBytes: 500},
+				{Timestamp: base.Add(2 * time.Minute), UsedBytes: 500},
+			},
+			wantOk: true,
+			want:   0,
+		},
+		{
+			name: "shrinking usage yields negative slope",
+			history: []UsageSample{
+				{Timestamp: base, UsedBytes: 100},
+				{Timestamp: base.Add(1 * time.Second), UsedBytes: 50},
+				{Timestamp: base.Add(2 * time.Second), UsedBytes: 0},
+			},
+			wantOk: true,
+			want:   -50,
+		},
+		{
+			name: "all samples at the same timestamp",
+			history: []UsageSample{
+				{Timestamp: base, UsedBytes: 10},
+				{Timestamp: base, UsedBytes: 20},
+			},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := fitLinearGrowthRate(tt.history)
+			if ok != tt.wantOk {
+				t.Fatalf("fitLinearGrowthRate() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if ok && got != tt.want {
+				t.Errorf("fitLinearGrowthRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapUpToAllocationUnit(t *testing.T) {
+	tests := []struct {
+		name       string
+		valueBytes int64
+		unitBytes  int64
+		want       int64
+	}{
+		{"exact multiple stays unchanged", 2 << 30, 1 << 30, 2 << 30},
+		{"rounds up to next unit", (2 << 30) + 1, 1 << 30, 3 << 30},
+		{"zero unit falls back to default", 1, 0, defaultAllocationUnitBytes},
+		{"negative unit falls back to default", 1, -1, defaultAllocationUnitBytes},
+		{"zero value snaps to zero", 0, 1 << 30, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := snapUpToAllocationUnit(tt.valueBytes, tt.unitBytes); got != tt.want {
+				t.Errorf("snapUpToAllocationUnit(%d, %d) = %d, want %d", tt.valueBytes, tt.unitBytes, got, tt.want)
+			}
+		})
+	}
+}
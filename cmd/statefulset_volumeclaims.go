@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PVCAutoscalerOwnerSyncAnnotation opts a PVC into keeping its owning
+// StatefulSet's volumeClaimTemplates in sync with resizes performed by the
+// autoscaler. The only supported value today is "statefulset".
+const PVCAutoscalerOwnerSyncAnnotation = "pvc.autoscaler.io/owner-sync"
+
+const ownerSyncStatefulSet = "statefulset"
+
+// statefulSetPodNameLabel is set by the StatefulSet controller on every PVC
+// it provisions from a volumeClaimTemplate.
+const statefulSetPodNameLabel = "statefulset.kubernetes.io/pod-name"
+
+// Retry parameters for recreating a StatefulSet after it was deleted with
+// cascade=orphan. A failure here leaves pods and PVCs orphaned with no owner
+// until the StatefulSet exists again, so it's worth retrying hard before
+// giving up and surfacing it to a human.
+const (
+	statefulSetRecreateMaxAttempts = 5
+	statefulSetRecreateBaseBackoff = 2 * time.Second
+)
+
+// ownerStatefulSetName returns the name of the StatefulSet that owns pvc, and
+// whether one was found. It first looks at OwnerReferences, then falls back
+// to the statefulset.kubernetes.io/pod-name label, stripping the pod's
+// ordinal suffix.
+func ownerStatefulSetName(pvc *corev1.PersistentVolumeClaim) (string, bool) {
+	for _, ref := range pvc.OwnerReferences {
+		if ref.Kind == "StatefulSet" {
+			return ref.Name, true
+		}
+	}
+
+	podName, ok := pvc.Labels[statefulSetPodNameLabel]
+	if !ok {
+		return "", false
+	}
+	idx := strings.LastIndex(podName, "-")
+	if idx == -1 {
+		return "", false
+	}
+	return podName[:idx], true
+}
+
+// templateNameForPVC returns the volumeClaimTemplate name a PVC was
+// provisioned from, e.g. a PVC named "data-myapp-0" owned by StatefulSet
+// "myapp" was provisioned from the template "data".
+func templateNameForPVC(pvc *corev1.PersistentVolumeClaim, stsName string) (string, bool) {
+	suffix := "-" + stsName + "-"
+	idx := strings.Index(pvc.Name, suffix)
+	if idx <= 0 {
+		return "", false
+	}
+	return pvc.Name[:idx], true
+}
+
+// syncStatefulSetVolumeClaimTemplate keeps the volumeClaimTemplate entry
+// named templateName on the StatefulSet stsName in sync with newSize, so
+// that future replicas (and rollouts) don't reset the PVC back to its old
+// size. volumeClaimTemplates are immutable on a running StatefulSet, so the
+// only way to change one is to delete the StatefulSet with
+// cascade=orphan (leaving its pods and PVCs untouched), patch the template,
+// and recreate it. This mirrors the approach VictoriaMetrics's operator
+// takes in growSTSPVC.
+//
+// skipPVCName is excluded from the replica PVC grow pass: it names the PVC
+// the caller is already resizing itself in the same reconcile iteration, and
+// resizing it here too would bump its ResourceVersion out from under the
+// caller's subsequent writes.
+func (a *PVCAutoscaler) syncStatefulSetVolumeClaimTemplate(ctx context.Context, namespace, stsName, templateName, skipPVCName string, newSize *resource.Quantity) error {
+	sts, err := a.kubeClient.AppsV1().StatefulSets(namespace).Get(ctx, stsName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get StatefulSet %s/%s: %w", namespace, stsName, err)
+	}
+
+	tplIdx := -1
+	for i, tpl := range sts.Spec.VolumeClaimTemplates {
+		if tpl.Name == templateName {
+			tplIdx = i
+			break
+		}
+	}
+	if tplIdx == -1 {
+		return fmt.Errorf("StatefulSet %s/%s has no volumeClaimTemplate named %q", namespace, stsName, templateName)
+	}
+
+	currentSize := sts.Spec.VolumeClaimTemplates[tplIdx].Spec.Resources.Requests[corev1.ResourceStorage]
+	if currentSize.Cmp(*newSize) >= 0 {
+		a.logger.WithFields(log.Fields{
+			"statefulSet": stsName,
+			"template":    templateName,
+		}).Debug("volumeClaimTemplate already requests at least the new size, skipping sync")
+		return nil
+	}
+
+	if storageClassName := sts.Spec.VolumeClaimTemplates[tplIdx].Spec.StorageClassName; storageClassName != nil {
+		isExpandable, err := isStorageClassExpandable(ctx, a.kubeClient, *storageClassName)
+		if err != nil {
+			return fmt.Errorf("failed getting StorageClass for template %q: %w", templateName, err)
+		}
+		if !isExpandable {
+			return fmt.Errorf("StorageClass %q for template %q does not allow volume expansion", *storageClassName, templateName)
+		}
+	}
+
+	recreated := sts.DeepCopy()
+	recreated.Spec.VolumeClaimTemplates[tplIdx].Spec.Resources.Requests[corev1.ResourceStorage] = *newSize
+	recreated.ResourceVersion = ""
+	recreated.UID = ""
+
+	propagation := metav1.DeletePropagationOrphan
+	if err := a.kubeClient.AppsV1().StatefulSets(namespace).Delete(ctx, stsName, metav1.DeleteOptions{PropagationPolicy: &propagation}); err != nil {
+		return fmt.Errorf("failed to delete StatefulSet %s/%s with cascade=orphan: %w", namespace, stsName, err)
+	}
+
+	if err := a.recreateStatefulSetWithRetry(ctx, namespace, stsName, recreated); err != nil {
+		if evErr := a.emitEvent(ctx, recreated, "StatefulSet", corev1.EventTypeWarning, "StatefulSetRecreateFailed",
+			fmt.Sprintf("StatefulSet was deleted (cascade=orphan) to resize volumeClaimTemplate %q but could not be recreated: %v; its pods and PVCs are orphaned until it is recreated manually", templateName, err)); evErr != nil {
+			a.logger.WithField("error", evErr).Warn("failed to emit StatefulSetRecreateFailed event")
+		}
+		return fmt.Errorf("failed to recreate StatefulSet %s/%s with updated volumeClaimTemplate: %w", namespace, stsName, err)
+	}
+
+	if err := a.emitEvent(ctx, sts, "StatefulSet", corev1.EventTypeNormal, "VolumeClaimTemplateResized",
+		fmt.Sprintf("resized volumeClaimTemplate %q to %s", templateName, newSize.String())); err != nil {
+		a.logger.WithField("error", err).Warn("failed to emit event on StatefulSet")
+	}
+
+	return a.growStatefulSetReplicaPVCs(ctx, recreated, templateName, skipPVCName, newSize)
+}
+
+// recreateStatefulSetWithRetry creates sts, retrying with exponential
+// backoff on failure. It's called only after the StatefulSet has already
+// been deleted with cascade=orphan, so giving up early would leave it
+// missing (with its pods and PVCs orphaned) rather than just out of date.
+func (a *PVCAutoscaler) recreateStatefulSetWithRetry(ctx context.Context, namespace, stsName string, sts *appsv1.StatefulSet) error {
+	backoff := statefulSetRecreateBaseBackoff
+
+	var lastErr error
+	for attempt := 1; attempt <= statefulSetRecreateMaxAttempts; attempt++ {
+		_, err := a.kubeClient.AppsV1().StatefulSets(namespace).Create(ctx, sts, metav1.CreateOptions{})
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+
+		a.logger.WithFields(log.Fields{
+			"statefulSet": stsName,
+			"attempt":     attempt,
+			"error":       err,
+		}).Warn("failed to recreate StatefulSet after cascade=orphan delete, retrying")
+
+		if attempt == statefulSetRecreateMaxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return fmt.Errorf("gave up after %d attempts: %w", statefulSetRecreateMaxAttempts, lastErr)
+}
+
+// growStatefulSetReplicaPVCs resizes every existing replica PVC provisioned
+// from templateName to newSize, except skipPVCName. Recreating the
+// StatefulSet does not touch already-bound PVCs, so each one still has to be
+// grown individually; skipPVCName is the PVC the caller is already resizing
+// itself in the same reconcile iteration.
+func (a *PVCAutoscaler) growStatefulSetReplicaPVCs(ctx context.Context, sts *appsv1.StatefulSet, templateName, skipPVCName string, newSize *resource.Quantity) error {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+
+	for i := int32(0); i < replicas; i++ {
+		pvcName := fmt.Sprintf("%s-%s-%d", templateName, sts.Name, i)
+		if pvcName == skipPVCName {
+			continue
+		}
+		pvc, err := a.kubeClient.CoreV1().PersistentVolumeClaims(sts.Namespace).Get(ctx, pvcName, metav1.GetOptions{})
+		if err != nil {
+			a.logger.WithFields(log.Fields{"pvc": pvcName, "error": err}).Error("failed to get replica pvc while growing StatefulSet")
+			continue
+		}
+
+		existing := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if existing.Cmp(*newSize) >= 0 {
+			continue
+		}
+
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = *newSize
+		if _, err := a.kubeClient.CoreV1().PersistentVolumeClaims(sts.Namespace).Update(ctx, pvc, metav1.UpdateOptions{}); err != nil {
+			a.logger.WithFields(log.Fields{"pvc": pvcName, "error": err}).Error("failed to resize replica pvc while growing StatefulSet")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestOwnerStatefulSetName(t *testing.T) {
+	tests := []struct {
+		name     string
+		pvc      *corev1.PersistentVolumeClaim
+		wantName string
+		wantOk   bool
+	}{
+		{
+			name: "owner reference wins",
+			pvc: &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					OwnerReferences: []metav1.OwnerReference{{Kind: "StatefulSet", Name: "myapp"}},
+					Labels:          map[string]string{statefulSetPodNameLabel: "other-3"},
+				},
+			},
+			wantName: "myapp",
+			wantOk:   true,
+		},
+		{
+			name: "falls back to pod-name label",
+			pvc: &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{statefulSetPodNameLabel: "myapp-3"},
+				},
+			},
+			wantName: "myapp",
+			wantOk:   true,
+		},
+		{
+			name: "pod-name label without ordinal suffix",
+			pvc: &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{statefulSetPodNameLabel: "myapp"},
+				},
+			},
+			wantOk: false,
+		},
+		{
+			name:   "no owner reference or label",
+			pvc:    &corev1.PersistentVolumeClaim{},
+			wantOk: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotName, gotOk := ownerStatefulSetName(tt.pvc)
+			if gotOk != tt.wantOk {
+				t.Fatalf("ownerStatefulSetName() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+			if gotOk && gotName != tt.wantName {
+				t.Errorf("ownerStatefulSetName() = %q, want %q", gotName, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestTemplateNameForPVC(t *testing.T) {
+	tests := []struct {
+		name     string
+		pvcName  string
+		stsName  string
+		wantName string
+		wantOk   bool
+	}{
+		{"simple case", "data-myapp-0", "myapp", "data", true},
+		{"multi-segment template name", "archive-data-myapp-2", "myapp", "archive-data", true},
+		{"sts name not present", "data-other-0", "myapp", "", false},
+		{"pvc name equals sts suffix with no template prefix", "-myapp-0", "myapp", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: tt.pvcName}}
+			gotName, gotOk := templateNameForPVC(pvc, tt.stsName)
+			if gotOk != tt.wantOk {
+				t.Fatalf("templateNameForPVC() ok = %v, want %v", gotOk, tt.wantOk)
+			}
+			if gotOk && gotName != tt.wantName {
+				t.Errorf("templateNameForPVC() = %q, want %q", gotName, tt.wantName)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	log "github.com/sirupsen/logrus"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// PVCAutoscalerKickPodAnnotation opts a PVC into having its consuming Pod
+// deleted (to force a remount) once its filesystem resize is detected as
+// stalled. Off by default because deleting a Pod is disruptive.
+const PVCAutoscalerKickPodAnnotation = "pvc.autoscaler.io/kick-pod"
+
+// storageResizerAnnotation is set by the CSI external-resizer on the PV to
+// record which driver is handling the expansion.
+const storageResizerAnnotation = "volume.kubernetes.io/storage-resizer"
+
+// DefaultStuckResizeThreshold is how long FileSystemResizePending may persist
+// on a PVC before it's considered stalled.
+const DefaultStuckResizeThreshold = 10 * time.Minute
+
+// StuckResizeThreshold is set from the --stuck-resize-threshold flag at
+// startup.
+var StuckResizeThreshold = DefaultStuckResizeThreshold
+
+var stuckResizesTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "pvc_autoscaler_stuck_resizes_total",
+	Help: "Number of reconcile passes that found a PVC's FileSystemResizePending condition persisting past the stuck-resize threshold; a PVC that stays stalled is counted again on every pass.",
+})
+
+// pendingSince tracks, per PVC, when FileSystemResizePending was first
+// observed, so we can tell a fresh resize from one that's actually stuck.
+var pendingSince = struct {
+	sync.Mutex
+	m map[types.NamespacedName]time.Time
+}{m: make(map[types.NamespacedName]time.Time)}
+
+// checkStuckFilesystemResize inspects pvc's conditions for a
+// FileSystemResizePending that has outlived StuckResizeThreshold, meaning
+// the controller-side expansion succeeded but the node-side filesystem
+// resize never completed (e.g. the consuming pod isn't running, or its CSI
+// driver crashed). When that happens it emits a FilesystemResizeStalled
+// event, increments pvc_autoscaler_stuck_resizes_total, and, if the PVC
+// opted in via PVCAutoscalerKickPodAnnotation, deletes the consuming pod to
+// force a remount.
+func (a *PVCAutoscaler) checkStuckFilesystemResize(ctx context.Context, pvc *corev1.PersistentVolumeClaim) error {
+	namespacedName := types.NamespacedName{Namespace: pvc.Namespace, Name: pvc.Name}
+	pvcId := fmt.Sprintf("%s/%s", pvc.Namespace, pvc.Name)
+
+	resizing, pending := pvcResizeConditions(pvc)
+	if !pending {
+		pendingSince.Lock()
+		delete(pendingSince.m, namespacedName)
+		pendingSince.Unlock()
+		return nil
+	}
+
+	pendingSince.Lock()
+	since, tracked := pendingSince.m[namespacedName]
+	if !tracked {
+		since = time.Now()
+		pendingSince.m[namespacedName] = since
+	}
+	pendingSince.Unlock()
+
+	stalledFor := time.Since(since)
+	if stalledFor < StuckResizeThreshold {
+		a.logger.WithFields(log.Fields{
+			"id":         pvcId,
+			"resizing":   resizing,
+			"stalledFor": stalledFor,
+		}).Debug("pvc is waiting on a filesystem resize")
+		return nil
+	}
+
+	resizer := ""
+	if pv, err := a.kubeClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{}); err != nil {
+		a.logger.WithFields(log.Fields{"pvcId": pvcId, "error": err}).Warn("failed to get bound PersistentVolume while checking for a stuck resize")
+	} else {
+		resizer = pv.Annotations[storageResizerAnnotation]
+	}
+
+	stuckResizesTotal.Inc()
+
+	if err := a.emitEvent(ctx, pvc, "PersistentVolumeClaim", corev1.EventTypeWarning, "FilesystemResizeStalled",
+		fmt.Sprintf("filesystem resize has been pending for %s (resizer=%q); the controller-side expansion likely succeeded but the node-side resize has not", stalledFor.Round(time.Second), resizer)); err != nil {
+		a.logger.WithFields(log.Fields{"pvcId": pvcId, "error": err}).Warn("failed to emit FilesystemResizeStalled event")
+	}
+
+	if pvc.Annotations[PVCAutoscalerKickPodAnnotation] != "true" {
+		return nil
+	}
+
+	pod, err := a.findPodMountingPVC(ctx, pvc)
+	if err != nil {
+		return fmt.Errorf("failed to find pod consuming PVC %s to kick it: %w", pvcId, err)
+	}
+	if pod == nil {
+		a.logger.WithField("id", pvcId).Warn("kick-pod requested but no pod currently mounts this pvc")
+		return nil
+	}
+
+	if err := a.kubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+		return fmt.Errorf("failed to delete pod %s/%s to force a remount: %w", pod.Namespace, pod.Name, err)
+	}
+	a.logger.WithFields(log.Fields{"pvcId": pvcId, "pod": pod.Name}).Info("deleted pod to force a remount after a stalled filesystem resize")
+
+	return nil
+}
+
+// pvcResizeConditions reports whether pvc currently has the Resizing and/or
+// FileSystemResizePending conditions set to True.
+func pvcResizeConditions(pvc *corev1.PersistentVolumeClaim) (resizing, pending bool) {
+	for _, cond := range pvc.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case corev1.PersistentVolumeClaimResizing:
+			resizing = true
+		case corev1.PersistentVolumeClaimFileSystemResizePending:
+			pending = true
+		}
+	}
+	return resizing, pending
+}
+
+// findPodMountingPVC returns the first Pod in pvc's namespace that mounts it
+// by name, or nil if none is found.
+func (a *PVCAutoscaler) findPodMountingPVC(ctx context.Context, pvc *corev1.PersistentVolumeClaim) (*corev1.Pod, error) {
+	pods, err := a.kubeClient.CoreV1().Pods(pvc.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods in namespace %s: %w", pvc.Namespace, err)
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		for _, vol := range pod.Spec.Volumes {
+			if vol.PersistentVolumeClaim != nil && vol.PersistentVolumeClaim.ClaimName == pvc.Name {
+				return pod, nil
+			}
+		}
+	}
+
+	return nil, nil
+}